@@ -0,0 +1,169 @@
+// Package config loads tetrisrun's keybinding and drop-rate settings
+// from a TOML config file, falling back to the built-in defaults for
+// anything the file does not set.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/wspace-lang/pdewacht-tetris/tetrisrun/input"
+)
+
+// Curve selects how the drop rate changes as the game progresses.
+type Curve string
+
+const (
+	CurveLinear      Curve = "linear"
+	CurveExponential Curve = "exponential"
+	CurveTable       Curve = "table"
+)
+
+// Step is one entry of a piecewise "table" curve: once at least Tick
+// drops have happened, the rate becomes Rate.
+type Step struct {
+	Tick int
+	Rate time.Duration
+}
+
+// Speed holds the drop-rate curve settings.
+type Speed struct {
+	Initial time.Duration
+	Final   time.Duration
+	Delta   time.Duration
+	Curve   Curve
+	Table   []Step
+}
+
+// Config is tetrisrun's fully resolved configuration: the built-in
+// defaults overlaid with anything set in a config file.
+type Config struct {
+	Keys  input.KeyMap
+	Speed Speed
+}
+
+// Default returns tetrisrun's built-in keybindings and drop-rate
+// curve, matching its long-standing hard-coded behavior.
+func Default() *Config {
+	return &Config{
+		Keys: input.KeyMap{
+			"i": input.ActionRotate, "w": input.ActionRotate, "Up": input.ActionRotate,
+			"j": input.ActionLeft, "a": input.ActionLeft, "Left": input.ActionLeft,
+			"k": input.ActionDrop, "s": input.ActionDrop, "Down": input.ActionDrop,
+			"l": input.ActionRight, "d": input.ActionRight, "Right": input.ActionRight,
+			"q": input.ActionQuit, "Escape": input.ActionQuit,
+			"p": input.ActionPause, "Space": input.ActionPause,
+		},
+		Speed: Speed{
+			Initial: 1000 * time.Millisecond,
+			Final:   400 * time.Microsecond,
+			Delta:   1 * time.Millisecond,
+			Curve:   CurveLinear,
+		},
+	}
+}
+
+// DefaultPath returns ~/.config/tetrisrun/config.toml, or "" if the
+// user's home directory can't be determined.
+func DefaultPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "tetrisrun", "config.toml")
+}
+
+// file is the on-disk TOML shape; durations are spelled out in units
+// that keep the common values as small whole numbers.
+type file struct {
+	Keys  map[string]string `toml:"keys"`
+	Speed struct {
+		InitialMS int64  `toml:"initial_ms"`
+		FinalUS   int64  `toml:"final_us"`
+		DeltaMS   int64  `toml:"delta_ms"`
+		Curve     string `toml:"curve"`
+		Table     []struct {
+			Tick   int   `toml:"tick"`
+			RateMS int64 `toml:"rate_ms"`
+		} `toml:"table"`
+	} `toml:"speed"`
+}
+
+// Load reads the TOML config file at path and overlays it onto
+// Default. A missing file is not an error: Load simply returns the
+// defaults. An empty path also returns the defaults.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if path == "" {
+		return cfg, nil
+	}
+
+	var f file
+	if _, err := toml.DecodeFile(path, &f); err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, fmt.Errorf("config: %w", err)
+	}
+
+	for key, action := range f.Keys {
+		cfg.Keys[key] = input.Action(action)
+	}
+
+	if f.Speed.InitialMS != 0 {
+		cfg.Speed.Initial = time.Duration(f.Speed.InitialMS) * time.Millisecond
+	}
+	if f.Speed.FinalUS != 0 {
+		cfg.Speed.Final = time.Duration(f.Speed.FinalUS) * time.Microsecond
+	}
+	if f.Speed.DeltaMS != 0 {
+		cfg.Speed.Delta = time.Duration(f.Speed.DeltaMS) * time.Millisecond
+	}
+	if f.Speed.Curve != "" {
+		cfg.Speed.Curve = Curve(f.Speed.Curve)
+	}
+	for _, s := range f.Speed.Table {
+		cfg.Speed.Table = append(cfg.Speed.Table, Step{Tick: s.Tick, Rate: time.Duration(s.RateMS) * time.Millisecond})
+	}
+	sort.Slice(cfg.Speed.Table, func(i, j int) bool { return cfg.Speed.Table[i].Tick < cfg.Speed.Table[j].Tick })
+
+	return cfg, nil
+}
+
+// NextDropRate returns the drop rate to use after ticks drops have
+// happened, given the drop rate currently in effect, following the
+// curve configured in speed.
+func NextDropRate(speed Speed, current time.Duration, ticks int) time.Duration {
+	switch speed.Curve {
+	case CurveTable:
+		rate := speed.Initial
+		for _, s := range speed.Table {
+			if ticks < s.Tick {
+				break
+			}
+			rate = s.Rate
+		}
+		return rate
+	case CurveExponential:
+		if speed.Initial <= 0 {
+			return speed.Final
+		}
+		shrink := float64(speed.Delta) / float64(speed.Initial)
+		next := current - time.Duration(float64(current)*shrink)
+		if next < speed.Final {
+			next = speed.Final
+		}
+		return next
+	default: // CurveLinear
+		next := current - speed.Delta
+		if next < speed.Final {
+			next = speed.Final
+		}
+		return next
+	}
+}
@@ -0,0 +1,184 @@
+//go:build linux
+
+package input
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"unsafe"
+)
+
+// Linux evdev event types and codes tetrisrun cares about. See
+// <linux/input-event-codes.h>.
+const (
+	evKey = 0x01
+	evAbs = 0x03
+
+	btnSouth  = 0x130
+	btnEast   = 0x131
+	btnNorth  = 0x133
+	btnWest   = 0x134
+	btnSelect = 0x13a
+	btnStart  = 0x13b
+
+	btnDpadUp    = 0x220
+	btnDpadDown  = 0x221
+	btnDpadLeft  = 0x222
+	btnDpadRight = 0x223
+
+	absHat0X = 0x10
+	absHat0Y = 0x11
+)
+
+// inputEventSize is sizeof(struct input_event) on 64-bit Linux: a
+// 16-byte struct timeval, a __u16 type, a __u16 code and a __s32
+// value.
+const inputEventSize = 24
+
+// gamepadReader reads D-pad and button presses from a Linux evdev
+// device node, translating them into the same actions a keyboard
+// produces.
+type gamepadReader struct {
+	f     *os.File
+	pause chan bool
+}
+
+// NewGamepadReader opens the first /dev/input/event* device that
+// reports itself as a gamepad (a D-pad hat axis or gamepad-range
+// buttons, via EVIOCGBIT) and returns a KeyReader that reads input
+// from it. Devices are tried in name order.
+func NewGamepadReader(pause chan bool) (KeyReader, error) {
+	f, err := findGamepadDevice()
+	if err != nil {
+		return nil, err
+	}
+	return &gamepadReader{f, pause}, nil
+}
+
+func findGamepadDevice() (*os.File, error) {
+	matches, err := filepath.Glob("/dev/input/event*")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	for _, m := range matches {
+		f, err := os.Open(m)
+		if err != nil {
+			continue
+		}
+		if isGamepad(f) {
+			return f, nil
+		}
+		f.Close()
+	}
+	return nil, errors.New("no gamepad found among /dev/input/event* devices (check permissions)")
+}
+
+// isGamepad reports whether f's device exposes a D-pad hat axis
+// (ABS_HAT0X) or the south face button (BTN_SOUTH), so tetrisrun doesn't
+// mistake an arbitrary readable input device (a power button, a
+// keyboard) for a controller.
+func isGamepad(f *os.File) bool {
+	return deviceHasBit(f, evAbs, absHat0X) || deviceHasBit(f, evKey, btnSouth)
+}
+
+// bitmapSize covers event codes up to KEY_MAX (0x2ff), which is
+// larger than any EV_KEY or EV_ABS code tetrisrun queries.
+const bitmapSize = 0x2ff/8 + 1
+
+// deviceHasBit reports whether f's device advertises support for
+// event type evType, code bit, via the EVIOCGBIT ioctl.
+func deviceHasBit(f *os.File, evType, bit uint16) bool {
+	var bitmap [bitmapSize]byte
+	req := eviocgbit(evType, len(bitmap))
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), req, uintptr(unsafe.Pointer(&bitmap[0])))
+	if errno != 0 {
+		return false
+	}
+	return bitmap[bit/8]&(1<<(bit%8)) != 0
+}
+
+// eviocgbit reproduces the Linux EVIOCGBIT(ev, len) ioctl request
+// code from <linux/input.h>, which asm-generic/ioctl.h builds as
+// _IOC(_IOC_READ, 'E', 0x20+ev, len).
+func eviocgbit(ev uint16, length int) uintptr {
+	const (
+		dirShift  = 30
+		typeShift = 8
+		sizeShift = 16
+		ioctlRead = 2
+	)
+	return ioctlRead<<dirShift | uintptr('E')<<typeShift | uintptr(0x20+ev) | uintptr(length)<<sizeShift
+}
+
+func (g *gamepadReader) ReadKey() (Key, error) {
+	return dispatch(g.nextAction, g.pause)
+}
+
+// nextAction reads evdev events until one maps to a game action: D-pad
+// left, right or down, any face button (rotate), start (pause) or
+// select/back (quit). D-pad up is ignored.
+func (g *gamepadReader) nextAction() (Action, error) {
+	for {
+		ev, err := g.readEvent()
+		if err != nil {
+			return "", err
+		}
+		switch ev.typ {
+		case evKey:
+			if ev.value == 0 { // key release
+				continue
+			}
+			switch ev.code {
+			case btnDpadLeft:
+				return ActionLeft, nil
+			case btnDpadRight:
+				return ActionRight, nil
+			case btnDpadDown:
+				return ActionDrop, nil
+			case btnSouth, btnEast, btnNorth, btnWest:
+				return ActionRotate, nil
+			case btnStart:
+				return ActionPause, nil
+			case btnSelect:
+				return ActionQuit, nil
+			}
+		case evAbs:
+			switch ev.code {
+			case absHat0X:
+				if ev.value < 0 {
+					return ActionLeft, nil
+				} else if ev.value > 0 {
+					return ActionRight, nil
+				}
+			case absHat0Y:
+				if ev.value > 0 {
+					return ActionDrop, nil
+				}
+			}
+		}
+	}
+}
+
+type evdevEvent struct {
+	typ   uint16
+	code  uint16
+	value int32
+}
+
+func (g *gamepadReader) readEvent() (evdevEvent, error) {
+	var buf [inputEventSize]byte
+	if _, err := io.ReadFull(g.f, buf[:]); err != nil {
+		return evdevEvent{}, err
+	}
+	return evdevEvent{
+		typ:   binary.LittleEndian.Uint16(buf[16:18]),
+		code:  binary.LittleEndian.Uint16(buf[18:20]),
+		value: int32(binary.LittleEndian.Uint32(buf[20:24])),
+	}, nil
+}
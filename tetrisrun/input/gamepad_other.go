@@ -0,0 +1,11 @@
+//go:build !linux
+
+package input
+
+import "errors"
+
+// NewGamepadReader reports that gamepad input isn't wired up for this
+// OS yet; only the Linux evdev backend exists so far.
+func NewGamepadReader(pause chan bool) (KeyReader, error) {
+	return nil, errors.New("gamepad input is only supported on Linux")
+}
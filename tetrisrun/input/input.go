@@ -0,0 +1,106 @@
+// Package input reads key presses and translates them into the game
+// actions understood by tetris.ws, abstracting over the OS- and
+// device-specific ways those key presses arrive.
+package input
+
+import "io"
+
+// Key is one of the four movement actions tetrisrun forwards to
+// tetris.ws.
+type Key byte
+
+const (
+	Up    Key = 'i'
+	Left  Key = 'j'
+	Down  Key = 'k'
+	Right Key = 'l'
+)
+
+// Action is a logical input event a KeyReader can recognize, before
+// it is translated into a Key (or, for Pause and Quit, handled
+// directly by the reader).
+type Action string
+
+const (
+	ActionRotate Action = "rotate"
+	ActionLeft   Action = "left"
+	ActionDrop   Action = "drop"
+	ActionRight  Action = "right"
+	ActionPause  Action = "pause"
+	ActionQuit   Action = "quit"
+)
+
+// actionKey maps the four movement actions to the Key ReadKey
+// returns for them.
+var actionKey = map[Action]Key{
+	ActionRotate: Up,
+	ActionLeft:   Left,
+	ActionDrop:   Down,
+	ActionRight:  Right,
+}
+
+// KeyMap maps a physical key, named either by the literal character it
+// produces ("i", "q") or by one of the special names "Up", "Down",
+// "Left", "Right", "Escape" or "Space" (the space bar), to the Action
+// it triggers. Keys absent from the map are ignored.
+type KeyMap map[string]Action
+
+// KeyReader reads a single key press at a time, returning the game
+// action it maps to. ReadKey returns io.EOF once the player has asked
+// to quit or the input source has been closed.
+type KeyReader interface {
+	ReadKey() (Key, error)
+}
+
+// actionFunc reads one physical input event and resolves it to an
+// Action, blocking until one occurs.
+type actionFunc func() (Action, error)
+
+// dispatch drives the shared ReadKey state machine for every KeyReader
+// backend: movement actions are translated to a Key and returned,
+// Quit becomes io.EOF, and Pause is handled by sending a single pulse
+// on pause and then ignoring further input from this source until its
+// own pause action recurs, at which point a second pulse is sent.
+//
+// pause is a pulse, not a true/false pair: each press of a reader's
+// pause action sends exactly one value, and it is the caller's job
+// (not this reader's) to decide what a pulse means, so independent
+// readers (e.g. keyboard and gamepad) can share one pause channel
+// without needing to agree on whose pulse resumes whose pause.
+func dispatch(next actionFunc, pause chan<- bool) (Key, error) {
+	for {
+		action, err := next()
+		if err != nil {
+			return 0, err
+		}
+		switch action {
+		case ActionPause:
+			pause <- true
+			if err := ignoreUntilPause(next, pause); err != nil {
+				return 0, err
+			}
+		case ActionQuit:
+			return 0, io.EOF
+		default:
+			if key, ok := actionKey[action]; ok {
+				return key, nil
+			}
+			// Unmapped event; keep reading.
+		}
+	}
+}
+
+// ignoreUntilPause discards this reader's input until its pause
+// action recurs, then sends the matching pulse.
+func ignoreUntilPause(next actionFunc, pause chan<- bool) error {
+	for {
+		action, err := next()
+		if err != nil {
+			return err
+		}
+		if action == ActionPause {
+			pause <- true
+			return nil
+		}
+	}
+}
@@ -0,0 +1,106 @@
+//go:build !windows
+
+package input
+
+import (
+	"bufio"
+	"os"
+	"time"
+)
+
+const escTimeout = 100 * time.Millisecond
+
+// unixReader reads keys from an ANSI/VT terminal: bytes are looked up
+// in keys directly, except that the space bar is looked up as "Space",
+// and ANSI CSI arrow-key escape sequences are decoded byte by byte
+// into the named keys "Up", "Down", "Left" and "Right" before the same
+// lookup is applied.
+type unixReader struct {
+	r     *bufio.Reader
+	keys  KeyMap
+	pause chan bool
+}
+
+// NewKeyReader returns the Unix KeyReader, which reads from os.Stdin
+// and resolves key presses against keys.
+func NewKeyReader(keys KeyMap, pause chan bool) KeyReader {
+	return &unixReader{bufio.NewReader(os.Stdin), keys, pause}
+}
+
+func (u *unixReader) ReadKey() (Key, error) {
+	return dispatch(u.nextAction, u.pause)
+}
+
+// nextAction reads one key press - a plain byte or an ANSI escape
+// sequence - and resolves it against keys. It keeps reading past
+// bytes that map to no action, so callers never see them.
+func (u *unixReader) nextAction() (Action, error) {
+	for {
+		b, err := u.r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		var name string
+		switch b {
+		// These always quit, regardless of the configured keymap, so a
+		// player is never locked out of a raw terminal by a bad config.
+		case '\x00', '\x03', '\x04', '\x1a': // ^@, ^C, ^D, ^Z
+			return ActionQuit, nil
+		case '\x1b':
+			n, err := u.readEscapeName()
+			if err != nil {
+				return "", err
+			}
+			if n == "" {
+				continue
+			}
+			name = n
+		case ' ':
+			name = "Space"
+		default:
+			name = string(b)
+		}
+		if action, ok := u.keys[name]; ok {
+			return action, nil
+		}
+	}
+}
+
+// readEscapeName reads the rest of an ANSI escape sequence and
+// returns the named key it represents ("Up", "Down", "Left", "Right"
+// or "Escape" for a lone ESC), or "" if the sequence is not
+// recognized. If a bracket is not read within escTimeout, it is
+// treated as a lone ESC.
+func (u *unixReader) readEscapeName() (string, error) {
+	readBracket := make(chan bool, 1)
+	go func() {
+		// Try to read the next character
+		b, err := u.r.ReadByte()
+		readBracket <- err == nil && b == '['
+	}()
+	select {
+	// Handle ANSI arrow key escape sequences
+	case isBracket := <-readBracket:
+		if !isBracket {
+			return "Escape", nil
+		}
+		b, err := u.r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		switch b {
+		case 'A':
+			return "Up", nil
+		case 'B':
+			return "Down", nil
+		case 'C':
+			return "Right", nil
+		case 'D':
+			return "Left", nil
+		}
+		return "", nil
+	// Timeout for lone ESC
+	case <-time.After(escTimeout):
+		return "Escape", nil
+	}
+}
@@ -0,0 +1,130 @@
+//go:build windows
+
+package input
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	kernel32           = windows.NewLazySystemDLL("kernel32.dll")
+	procReadConsoleInW = kernel32.NewProc("ReadConsoleInputW")
+)
+
+const keyEvent = 0x0001
+
+// Virtual-key codes tetrisrun cares about. See the Windows
+// documentation for "Virtual-Key Codes".
+const (
+	vkUp     = 0x26
+	vkDown   = 0x28
+	vkLeft   = 0x25
+	vkRight  = 0x27
+	vkEscape = 0x1B
+	vkSpace  = 0x20
+)
+
+// keyEventRecord mirrors the Win32 KEY_EVENT_RECORD struct.
+type keyEventRecord struct {
+	bKeyDown          int32
+	wRepeatCount      uint16
+	wVirtualKeyCode   uint16
+	wVirtualScanCode  uint16
+	unicodeChar       uint16
+	dwControlKeyState uint32
+}
+
+// inputRecord mirrors the Win32 INPUT_RECORD struct. Only keyEvent
+// records are inspected, so the union only needs to be as large as
+// keyEventRecord.
+type inputRecord struct {
+	eventType uint16
+	_         uint16 // alignment padding
+	event     keyEventRecord
+}
+
+// windowsReader reads keys directly from the console input buffer via
+// ReadConsoleInput, so tetrisrun is playable from cmd.exe or
+// PowerShell without relying on ANSI escape sequence support.
+type windowsReader struct {
+	handle windows.Handle
+	keys   KeyMap
+	pause  chan bool
+}
+
+// NewKeyReader returns the Windows KeyReader, which reads from the
+// console's standard input handle and resolves key presses against
+// keys.
+func NewKeyReader(keys KeyMap, pause chan bool) KeyReader {
+	return &windowsReader{windows.Stdin, keys, pause}
+}
+
+func (w *windowsReader) ReadKey() (Key, error) {
+	return dispatch(w.nextAction, w.pause)
+}
+
+// nextAction reads one key-down console input event and resolves its
+// name against keys. It keeps reading past events that map to no
+// action (including key-up events), so callers never see them.
+func (w *windowsReader) nextAction() (Action, error) {
+	for {
+		name, err := w.readKeyName()
+		if err != nil {
+			return "", err
+		}
+		if name == "" {
+			continue
+		}
+		if action, ok := w.keys[name]; ok {
+			return action, nil
+		}
+	}
+}
+
+// readKeyName blocks for the next key-down console event and returns
+// the name it maps to, or "" for events that aren't a recognized,
+// pressed key.
+func (w *windowsReader) readKeyName() (string, error) {
+	var rec inputRecord
+	var read uint32
+	ret, _, err := procReadConsoleInW.Call(
+		uintptr(w.handle),
+		uintptr(unsafe.Pointer(&rec)),
+		1,
+		uintptr(unsafe.Pointer(&read)),
+	)
+	if ret == 0 {
+		return "", err
+	}
+	if rec.eventType != keyEvent || rec.event.bKeyDown == 0 {
+		return "", nil
+	}
+
+	switch rec.event.wVirtualKeyCode {
+	case vkUp:
+		return "Up", nil
+	case vkDown:
+		return "Down", nil
+	case vkLeft:
+		return "Left", nil
+	case vkRight:
+		return "Right", nil
+	case vkEscape:
+		return "Escape", nil
+	case vkSpace:
+		return "Space", nil
+	}
+	if c := rec.event.unicodeChar; c > 0 && c < 128 {
+		return string(rune(toLower(byte(c)))), nil
+	}
+	return "", nil
+}
+
+func toLower(b byte) byte {
+	if b >= 'A' && b <= 'Z' {
+		return b - 'A' + 'a'
+	}
+	return b
+}
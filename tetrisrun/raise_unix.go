@@ -0,0 +1,22 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// raiseSignal re-sends sig to this process (or, for a signal type we
+// can't re-raise, falls back to a plain failing exit), so the
+// process's exit status is the conventional 128+signal that scripts
+// and supervisors expect. The caller is expected to have already
+// called signal.Stop so the re-sent signal isn't caught again.
+func raiseSignal(sig os.Signal) {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		os.Exit(1)
+		return
+	}
+	syscall.Kill(syscall.Getpid(), s)
+}
@@ -0,0 +1,12 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// raiseSignal exits with a failing status. Windows has no equivalent
+// of the Unix 128+signal convention, so there is nothing useful to
+// re-raise sig as.
+func raiseSignal(sig os.Signal) {
+	os.Exit(1)
+}
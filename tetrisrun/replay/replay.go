@@ -0,0 +1,102 @@
+// Package replay records the byte stream tetrisrun forwards to
+// tetris.ws and plays it back later, reproducing a game exactly by
+// re-emitting the same bytes after the same delays.
+//
+// The on-disk format is a sequence of events, each a varint-encoded
+// microsecond delta since the previous event followed by the single
+// byte emitted at that time.
+package replay
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Recorder appends forwarded bytes to a file as they happen. It is
+// safe for concurrent use: tetrisrun calls Record from both the key
+// input and the drop timer, so every call is serialized on mu.
+type Recorder struct {
+	mu   sync.Mutex
+	w    *bufio.Writer
+	f    *os.File
+	last time.Time
+}
+
+// Create opens path for recording, truncating it if it already
+// exists.
+func Create(path string) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &Recorder{w: bufio.NewWriter(f), f: f, last: now}, nil
+}
+
+// Record appends b to the log, timestamped against the previous call
+// to Record (or against Create, for the first call).
+func (r *Recorder) Record(b byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	delta := now.Sub(r.last)
+	r.last = now
+
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(delta.Microseconds()))
+	if _, err := r.w.Write(buf[:n]); err != nil {
+		return err
+	}
+	return r.w.WriteByte(b)
+}
+
+// Close flushes pending output and closes the underlying file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.w.Flush(); err != nil {
+		r.f.Close()
+		return err
+	}
+	return r.f.Close()
+}
+
+// Player reads back a log written by a Recorder.
+type Player struct {
+	r *bufio.Reader
+	f *os.File
+}
+
+// Open opens path for playback.
+func Open(path string) (*Player, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Player{bufio.NewReader(f), f}, nil
+}
+
+// Next sleeps until the next recorded event is due, then returns its
+// byte. It returns io.EOF once the log is exhausted.
+func (p *Player) Next() (byte, error) {
+	delta, err := binary.ReadUvarint(p.r)
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return 0, err
+	}
+	time.Sleep(time.Duration(delta) * time.Microsecond)
+	return p.r.ReadByte()
+}
+
+// Close closes the underlying file.
+func (p *Player) Close() error {
+	return p.f.Close()
+}
@@ -3,16 +3,28 @@
 // Command tetrisrun is a driver for Peter De Wachter's Whitespace
 // Tetris game. It introduces gravity and provides several key mappings.
 //
-// For better results, disable input processing and echo back using
-// stty, then run tetris.ws with tetrisrun piped into it.
+// tetrisrun puts stdin into raw mode itself, so there is no need to
+// run stty beforehand. Pipe it into tetris.ws as usual.
 //
 // For example:
 //
 //     nebula-compile tetris.ws tetris
 //     go build -o tetrisrun/tetrisrun tetrisrun/tetrisrun.go
-//     stty raw -echo && tetrisrun/tetrisrun | ./tetris
+//     tetrisrun/tetrisrun | ./tetris
 //
-// Controls:
+// Controls and the drop-rate curve can both be overridden by a TOML
+// config file; see the config package for its format. By default
+// tetrisrun looks for one at ~/.config/tetrisrun/config.toml; -config
+// <file> names a different one.
+//
+// -record <file> captures every byte forwarded to tetris.ws so a game
+// can be reproduced later with -replay <file>, which reads that log
+// instead of live input or the drop timer.
+//
+// -gamepad reads D-pad and button presses from the first available
+// controller alongside the keyboard; see the input package.
+//
+// Default controls:
 //
 //     i / w / up arrow - rotate
 //     j / a / left arrow - move left
@@ -23,74 +35,204 @@
 package main
 
 import (
-	"bufio"
+	"flag"
 	"fmt"
 	"io"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
-)
 
-const (
-	escTimeout      = 100 * time.Millisecond
-	initialDropRate = 1000 * time.Millisecond
-	finalDropRate   = 400 * time.Microsecond
-	dropRateDelta   = 1 * time.Millisecond
+	"golang.org/x/term"
+
+	"github.com/wspace-lang/pdewacht-tetris/tetrisrun/config"
+	"github.com/wspace-lang/pdewacht-tetris/tetrisrun/input"
+	"github.com/wspace-lang/pdewacht-tetris/tetrisrun/replay"
 )
 
 var (
-	stdin = bufio.NewReader(os.Stdin)
-	done  = make(chan bool)
 	pause = make(chan bool)
+
+	raw        = flag.Bool("raw", true, "put stdin into raw mode; disable if you manage the terminal yourself (e.g. with stty)")
+	configPath = flag.String("config", config.DefaultPath(), "path to a TOML config file for keybindings and drop rate")
+	recordPath = flag.String("record", "", "record every byte forwarded to tetris.ws to this file, for later -replay")
+	replayPath = flag.String("replay", "", "replay a game previously captured with -record, instead of reading live input")
+	gamepad    = flag.Bool("gamepad", false, "also read input from the first available gamepad, alongside the keyboard")
+
+	recorder *replay.Recorder
 )
 
 func main() {
+	flag.Parse()
 	signal.Ignore(syscall.SIGPIPE)
-	dropRate := initialDropRate
 
-	// Forward key presses to stdout
-	go func() {
-		for {
-			select {
-			default:
-				key, err := readKey()
-				if err != nil {
-					if err != io.EOF {
-						fmt.Fprintln(os.Stderr, err)
-					}
-					writeByte('\x1b') // ESC quits the game
-					done <- true
-					return
-				}
-				if !writeByte(key) {
-					return
-				}
-			case <-done:
-				return
-			}
+	if *replayPath != "" {
+		runReplay(*replayPath)
+		return
+	}
+
+	if *recordPath != "" {
+		rec, err := replay.Create(*recordPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "tetrisrun:", err)
+		} else {
+			recorder = rec
+			defer rec.Close()
 		}
-	}()
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "tetrisrun:", err)
+		cfg = config.Default()
+	}
+
+	restore := enterRawMode()
+	defer restore()
+
+	dropRate := cfg.Speed.Initial
+	ticks := 0
 
-	// Move block downwards
+	// Every input source forwards the bytes it reads onto keys, so
+	// keyboard and gamepad input can be read concurrently.
+	keys := make(chan byte)
+	go forwardKeys(input.NewKeyReader(cfg.Keys, pause), keys)
+	if *gamepad {
+		gp, err := input.NewGamepadReader(pause)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "tetrisrun:", err)
+		} else {
+			go forwardKeys(gp, keys)
+		}
+	}
+
+	// Move block downwards and forward key presses to stdout, both
+	// from this one loop: pause is a pulse, sent once per pause key
+	// press by whichever reader's pause action fired, and paused is
+	// owned entirely by this loop, so it toggles correctly no matter
+	// which input source (keyboard or gamepad) sent the pulse. Reading
+	// keys only while not paused matters just as much as owning
+	// paused: a reader that hasn't itself seen a pause action (e.g.
+	// the gamepad, when the keyboard triggered the pause) must still
+	// be prevented from moving the piece, and since it can't send on
+	// keys until this loop receives, it simply blocks until resume.
+	paused := false
 Drop:
 	for {
+		if paused {
+			<-pause
+			paused = false
+			continue
+		}
 		select {
+		case b := <-keys:
+			if !writeByte(b) {
+				break Drop
+			}
+			if b == '\x1b' { // a reader quit
+				break Drop
+			}
 		case <-time.After(dropRate):
 			if !writeByte('k') {
 				break Drop
 			}
-			if dropRate > finalDropRate {
-				dropRate -= dropRateDelta
-			}
+			ticks++
+			dropRate = config.NextDropRate(cfg.Speed, dropRate, ticks)
 		case <-pause:
-			<-pause
-		case <-done:
-			break Drop
+			paused = true
 		}
 	}
 }
 
+// forwardKeys reads keys from r until it errors, sending each one to
+// out as a byte. On EOF (the player quit) it forwards ESC; other
+// errors are also treated as fatal so a disconnected gamepad doesn't
+// spin the goroutine.
+func forwardKeys(r input.KeyReader, out chan<- byte) {
+	for {
+		key, err := r.ReadKey()
+		if err != nil {
+			if err != io.EOF {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			out <- '\x1b' // ESC quits the game
+			return
+		}
+		out <- byte(key)
+	}
+}
+
+// runReplay reproduces a game previously captured with -record by
+// reading its log and re-emitting each byte after the same delay,
+// instead of reading live input or running the drop timer.
+func runReplay(path string) {
+	p, err := replay.Open(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "tetrisrun:", err)
+		os.Exit(1)
+	}
+	defer p.Close()
+
+	for {
+		b, err := p.Next()
+		if err != nil {
+			if err != io.EOF {
+				fmt.Fprintln(os.Stderr, "tetrisrun:", err)
+			}
+			return
+		}
+		if !writeByte(b) {
+			return
+		}
+	}
+}
+
+// enterRawMode puts stdin into raw mode when -raw is set and stdin is
+// a terminal, and arranges for the terminal to be restored on return
+// and on SIGINT, SIGTERM or SIGHUP. When stdin is not a terminal (for
+// example during scripted testing) or -raw is false, it prints a
+// diagnostic, if appropriate, and returns a no-op restore function.
+func enterRawMode() (restore func()) {
+	if !*raw {
+		return func() {}
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		fmt.Fprintln(os.Stderr, "tetrisrun: stdin is not a terminal, skipping raw mode")
+		return func() {}
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "tetrisrun: failed to enter raw mode:", err)
+		return func() {}
+	}
+
+	restored := false
+	restore = func() {
+		if restored {
+			return
+		}
+		restored = true
+		term.Restore(fd, state)
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		s := <-sig
+		restore()
+		signal.Stop(sig)
+		raiseSignal(s)
+	}()
+
+	return restore
+}
+
+// writeByte writes b to stdout and, if -record is set, appends it to
+// the recording. It reports whether the write succeeded; the caller
+// is expected to stop the game on false.
 func writeByte(b byte) bool {
 	_, err := os.Stdout.Write([]byte{b})
 	if err != nil {
@@ -98,78 +240,12 @@ func writeByte(b byte) bool {
 		if pe, ok := err.(*os.PathError); !ok || pe.Err != syscall.EPIPE {
 			fmt.Fprintln(os.Stderr, err)
 		}
-		done <- true
 		return false
 	}
-	return true
-}
-
-// readKey reads a key press and handles key aliases. Arrow keys and
-// wasd are translated to ijjl; q and various control keys are
-// translated to quit.
-func readKey() (byte, error) {
-	for {
-		b, err := stdin.ReadByte()
-		if err != nil {
-			return 0, err
-		}
-		switch b {
-		case 'i', 'w': // up
-			return 'i', nil
-		case 'j', 'a': // left
-			return 'j', nil
-		case 'k', 's': // down
-			return 'k', nil
-		case 'l', 'd': // right
-			return 'l', nil
-		case 'q', '\x00', '\x03', '\x04', '\x1a': // q, ^@, ^C, ^D, ^Z
-			return 0, io.EOF
-		case 'p', ' ':
-			pause <- true
-			for {
-				b, err := stdin.ReadByte()
-				if err != nil {
-					return 0, err
-				}
-				if b == 'p' || b == ' ' {
-					break
-				}
-			}
-			pause <- false
-		case '\x1b': // ESC
-			// Translate the ANSI escape sequences for arrow keys into ijkl
-			// and quit on ESC key press. If a bracket is not read within
-			// escTimeout, it is treated as plain ESC.
-			readBracket := make(chan bool, 1)
-			go func() {
-				// Try to read the next character
-				b, err := stdin.ReadByte()
-				readBracket <- err == nil && b == '['
-			}()
-			select {
-			// Handle ANSI arrow key escape sequences
-			case isBracket := <-readBracket:
-				if !isBracket {
-					return 0, io.EOF
-				}
-				b, err := stdin.ReadByte()
-				if err != nil {
-					return 0, err
-				}
-				switch b {
-				case 'A': // up
-					return 'i', nil
-				case 'B': // down
-					return 'k', nil
-				case 'C': // right
-					return 'l', nil
-				case 'D': // left
-					return 'j', nil
-				}
-			// Timeout for lone ESC
-			case <-time.After(escTimeout):
-				return 0, io.EOF
-			}
+	if recorder != nil {
+		if err := recorder.Record(b); err != nil {
+			fmt.Fprintln(os.Stderr, "tetrisrun: record:", err)
 		}
 	}
+	return true
 }